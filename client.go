@@ -0,0 +1,201 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package gidari
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/alpstable/gidari/proto"
+)
+
+var (
+	// ErrTransport is returned by "Do" when the client fails to make the
+	// request.
+	ErrTransport = fmt.Errorf("transport error")
+
+	// ErrUnexpectedStatus is returned by "Do" when the response status
+	// code is not in the 2xx range.
+	ErrUnexpectedStatus = fmt.Errorf("unexpected status")
+
+	// ErrDecode is returned by "Do" when the response body cannot be
+	// decoded into the requested type.
+	ErrDecode = fmt.Errorf("decode error")
+)
+
+// maxErrorBodyLen is the maximum number of bytes of an unexpected response
+// body that are kept on a "DoError".
+const maxErrorBodyLen = 4096
+
+// DoError is the error returned by "Do" when a request fails. It matches its
+// Kind with "errors.Is", and unwraps to the underlying cause, so callers can
+// check for both, e.g. "ErrTransport" and "context.Canceled".
+type DoError struct {
+	// Kind is the sentinel error for the stage that failed, i.e.
+	// "ErrTransport", "ErrUnexpectedStatus", or "ErrDecode".
+	Kind error
+
+	// Err is the underlying cause of the failure.
+	Err error
+
+	// StatusCode is the response status code. It is only set when Kind
+	// is "ErrUnexpectedStatus".
+	StatusCode int
+
+	// Body is a prefix of at most 4096 bytes of the response body, e.g.
+	// an error message from the API. It is only set when Kind is
+	// "ErrUnexpectedStatus".
+	Body []byte
+}
+
+// Error implements the "error" interface.
+func (e *DoError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Kind, e.Err)
+}
+
+// Is will check if the target is the Kind of the error.
+func (e *DoError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Unwrap will return the underlying cause of the error.
+func (e *DoError) Unwrap() error {
+	return e.Err
+}
+
+// newContentDecoder will wrap the response body with a reader for the
+// response "Content-Encoding". Bodies that were already decompressed by the
+// transport are returned as-is.
+func newContentDecoder(rsp *http.Response) (io.Reader, error) {
+	if rsp.Uncompressed {
+		return rsp.Body, nil
+	}
+
+	switch encoding := strings.ToLower(strings.TrimSpace(rsp.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+		return rsp.Body, nil
+	case "gzip", "x-gzip":
+		reader, err := gzip.NewReader(rsp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+		}
+
+		return reader, nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+}
+
+// defaultContentType is the media type assumed for responses that do not set a
+// "Content-Type" header.
+const defaultContentType = "application/json"
+
+// checkCharset will ensure that the charset parameter of the response
+// "Content-Type" can be decoded. Only UTF-8 (and its subset, US-ASCII) is
+// supported, which is the default when no charset is set.
+func checkCharset(params map[string]string) error {
+	switch charset := strings.ToLower(params["charset"]); charset {
+	case "", "utf-8", "utf8", "us-ascii":
+		return nil
+	default:
+		return fmt.Errorf("unsupported charset %q", charset)
+	}
+}
+
+// Do will make the request using the provided client and decode the response
+// body into a value of type T. The decode type is chosen by matching the media
+// type of the response "Content-Type" header against the media types supported
+// by gidari, defaulting to JSON when the header is not set. Gzip content
+// encodings are decompressed before decoding.
+//
+// Authentication, rate limiting, and other middleware can be composed by
+// setting the "Transport" of an "http.Client", e.g. with an
+// "AcceptLanguageTransport", and passing it as the client.
+//
+// Errors are wrapped by "ErrTransport" if the request could not be made,
+// "ErrUnexpectedStatus" if the response status is not 2xx,
+// "proto.ErrUnsupportedDecodeType" if the content type cannot be negotiated,
+// and "ErrDecode" if the response body cannot be decoded. Transport, status,
+// and decode errors are returned as a "*DoError", which also unwraps to the
+// underlying cause. For status errors, it carries the status code and a prefix
+// of the response body.
+func Do[T any](ctx context.Context, client Client, req *http.Request) (T, error) {
+	var result T
+
+	rsp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return result, &DoError{Kind: ErrTransport, Err: err}
+	}
+
+	// Drain the body before closing it, so that the connection can be
+	// reused.
+	defer func() {
+		_, _ = io.Copy(io.Discard, rsp.Body)
+		_ = rsp.Body.Close()
+	}()
+
+	if rsp.StatusCode < http.StatusOK || rsp.StatusCode >= http.StatusMultipleChoices {
+		body, err := io.ReadAll(io.LimitReader(rsp.Body, maxErrorBodyLen))
+		if err != nil {
+			return result, &DoError{Kind: ErrTransport, Err: fmt.Errorf("failed to read response body: %w", err)}
+		}
+
+		return result, &DoError{
+			Kind:       ErrUnexpectedStatus,
+			Err:        fmt.Errorf("status %q", rsp.Status),
+			StatusCode: rsp.StatusCode,
+			Body:       body,
+		}
+	}
+
+	contentType := rsp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = defaultContentType
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return result, fmt.Errorf("%w: %q", proto.ErrUnsupportedDecodeType, contentType)
+	}
+
+	decodeType, ok := contentTypeDecodeType(mediaType)
+	if !ok {
+		return result, fmt.Errorf("%w: %q", proto.ErrUnsupportedDecodeType, contentType)
+	}
+
+	if err := checkCharset(params); err != nil {
+		return result, &DoError{Kind: ErrDecode, Err: err}
+	}
+
+	body, err := newContentDecoder(rsp)
+	if err != nil {
+		return result, &DoError{Kind: ErrDecode, Err: err}
+	}
+
+	switch decodeType {
+	case proto.DecodeTypeJSON:
+		if err := json.NewDecoder(body).Decode(&result); err != nil {
+			return result, &DoError{
+				Kind: ErrDecode,
+				Err:  fmt.Errorf("failed to decode %s: %w", mediaType, err),
+			}
+		}
+	case proto.DecodeTypeUnknown:
+		fallthrough
+	default:
+		return result, fmt.Errorf("%w: %s", proto.ErrUnsupportedDecodeType, decodeType)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,308 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package gidari
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/alpstable/gidari/proto"
+)
+
+// trackedBody is a response body that records whether it was drained and
+// closed.
+type trackedBody struct {
+	*bytes.Buffer
+	closed bool
+}
+
+func (body *trackedBody) Close() error {
+	body.closed = true
+
+	return nil
+}
+
+func gzipString(t *testing.T, str string) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write([]byte(str)); err != nil {
+		t.Fatalf("failed to write gzip: %v", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to close gzip writer: %v", err)
+	}
+
+	return buf.String()
+}
+
+func TestDo(t *testing.T) {
+	t.Parallel()
+
+	type character struct {
+		Name string `json:"name"`
+	}
+
+	errTransport := errors.New("connection refused")
+
+	for _, tcase := range []struct {
+		name string
+
+		// header is the response header.
+		header http.Header
+
+		// status is the response status code, defaults to 200.
+		status int
+
+		// body is the response body.
+		body string
+
+		// err is the error returned by the transport.
+		err error
+
+		// cancel will cancel the request context before calling Do.
+		cancel bool
+
+		want    character
+		wantErr error
+
+		// wantCause is the underlying error that Do is expected to
+		// unwrap to, if any.
+		wantCause error
+
+		// wantSyntaxErr will check that Do unwraps to a JSON syntax
+		// error, if true.
+		wantSyntaxErr bool
+
+		// wantStatusCode and wantBody are the status code and body
+		// prefix expected on a status error.
+		wantStatusCode int
+		wantBody       string
+	}{
+		{
+			name:   "json",
+			header: http.Header{"Content-Type": {"application/json; charset=utf-8"}},
+			body:   `{"name": "Jon Snow"}`,
+			want:   character{Name: "Jon Snow"},
+		},
+		{
+			name: "no content type",
+			body: `{"name": "Jon Snow"}`,
+			want: character{Name: "Jon Snow"},
+		},
+		{
+			name:   "json suffix",
+			header: http.Header{"Content-Type": {"application/vnd.api+json"}},
+			body:   `{"name": "Jon Snow"}`,
+			want:   character{Name: "Jon Snow"},
+		},
+		{
+			name: "gzip",
+			header: http.Header{
+				"Content-Type":     {"application/json"},
+				"Content-Encoding": {"gzip"},
+			},
+			body: gzipString(t, `{"name": "Jon Snow"}`),
+			want: character{Name: "Jon Snow"},
+		},
+		{
+			name:      "transport error",
+			err:       errTransport,
+			wantErr:   ErrTransport,
+			wantCause: errTransport,
+		},
+		{
+			name:      "canceled context",
+			cancel:    true,
+			wantErr:   ErrTransport,
+			wantCause: context.Canceled,
+		},
+		{
+			name:           "unexpected status",
+			status:         http.StatusForbidden,
+			body:           `{"message": "forbidden"}`,
+			wantErr:        ErrUnexpectedStatus,
+			wantStatusCode: http.StatusForbidden,
+			wantBody:       `{"message": "forbidden"}`,
+		},
+		{
+			name:           "unexpected status with large body",
+			status:         http.StatusUnauthorized,
+			body:           strings.Repeat("a", 2*maxErrorBodyLen),
+			wantErr:        ErrUnexpectedStatus,
+			wantStatusCode: http.StatusUnauthorized,
+			wantBody:       strings.Repeat("a", maxErrorBodyLen),
+		},
+		{
+			name:    "unsupported content type",
+			header:  http.Header{"Content-Type": {"text/html"}},
+			body:    `<html></html>`,
+			wantErr: proto.ErrUnsupportedDecodeType,
+		},
+		{
+			name:   "mixed case",
+			header: http.Header{"Content-Type": {"Application/JSON; Charset=UTF-8"}},
+			body:   `{"name": "Jon Snow"}`,
+			want:   character{Name: "Jon Snow"},
+		},
+		{
+			name:    "type wildcard",
+			header:  http.Header{"Content-Type": {"application/*"}},
+			body:    `{"name": "Jon Snow"}`,
+			wantErr: proto.ErrUnsupportedDecodeType,
+		},
+		{
+			name:    "wildcard",
+			header:  http.Header{"Content-Type": {"*/*"}},
+			body:    `{"name": "Jon Snow"}`,
+			wantErr: proto.ErrUnsupportedDecodeType,
+		},
+		{
+			name:    "list of media types",
+			header:  http.Header{"Content-Type": {"text/plain, application/json"}},
+			body:    `{"name": "Jon Snow"}`,
+			wantErr: proto.ErrUnsupportedDecodeType,
+		},
+		{
+			name:   "media type with qvalue",
+			header: http.Header{"Content-Type": {"application/json;q=0"}},
+			body:   `{"name": "Jon Snow"}`,
+			want:   character{Name: "Jon Snow"},
+		},
+		{
+			name:    "unsupported charset",
+			header:  http.Header{"Content-Type": {"application/json; charset=iso-8859-1"}},
+			body:    `{"name": "Jon Snow"}`,
+			wantErr: ErrDecode,
+		},
+		{
+			name:    "unsupported content encoding",
+			header:  http.Header{"Content-Encoding": {"br"}},
+			body:    `{"name": "Jon Snow"}`,
+			wantErr: ErrDecode,
+		},
+		{
+			name:      "invalid gzip",
+			header:    http.Header{"Content-Encoding": {"gzip"}},
+			body:      `{"name": "Jon Snow"}`,
+			wantErr:   ErrDecode,
+			wantCause: gzip.ErrHeader,
+		},
+		{
+			name:          "invalid json",
+			header:        http.Header{"Content-Type": {"application/json"}},
+			body:          `{"name": }`,
+			wantErr:       ErrDecode,
+			wantSyntaxErr: true,
+		},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			body := &trackedBody{Buffer: bytes.NewBufferString(tcase.body)}
+
+			client := &http.Client{
+				Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					if err := req.Context().Err(); err != nil {
+						return nil, err
+					}
+
+					if tcase.err != nil {
+						return nil, tcase.err
+					}
+
+					status := tcase.status
+					if status == 0 {
+						status = http.StatusOK
+					}
+
+					header := tcase.header
+					if header == nil {
+						header = http.Header{}
+					}
+
+					return &http.Response{
+						Status:     http.StatusText(status),
+						StatusCode: status,
+						Header:     header,
+						Body:       body,
+						Request:    req,
+					}, nil
+				}),
+			}
+
+			req, err := http.NewRequest(http.MethodGet, "http://example/characters/583", nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			if tcase.cancel {
+				cancel()
+			}
+
+			got, err := Do[character](ctx, client, req)
+			if !errors.Is(err, tcase.wantErr) {
+				t.Fatalf("expected error %v, got %v", tcase.wantErr, err)
+			}
+
+			if tcase.wantCause != nil && !errors.Is(err, tcase.wantCause) {
+				t.Fatalf("expected error to unwrap to %v, got %v", tcase.wantCause, err)
+			}
+
+			var syntaxErr *json.SyntaxError
+			if tcase.wantSyntaxErr && !errors.As(err, &syntaxErr) {
+				t.Fatalf("expected error to unwrap to a JSON syntax error, got %v", err)
+			}
+
+			var doErr *DoError
+			if err != nil && !errors.As(err, &doErr) && !errors.Is(err, proto.ErrUnsupportedDecodeType) {
+				t.Fatalf("expected a *DoError, got %T", err)
+			}
+
+			if tcase.wantStatusCode != 0 {
+				if doErr.StatusCode != tcase.wantStatusCode {
+					t.Fatalf("expected status code %d, got %d", tcase.wantStatusCode, doErr.StatusCode)
+				}
+
+				if string(doErr.Body) != tcase.wantBody {
+					t.Fatalf("expected body %q, got %q", tcase.wantBody, doErr.Body)
+				}
+			}
+
+			// The body should be drained and closed so that the
+			// connection can be reused.
+			if tcase.err == nil && !tcase.cancel && (body.Len() != 0 || !body.closed) {
+				t.Fatalf("expected body to be drained and closed, %d bytes left", body.Len())
+			}
+
+			var urlErr *url.Error
+			if errors.Is(err, ErrTransport) && !errors.As(err, &urlErr) {
+				t.Fatalf("expected error to unwrap to a URL error, got %v", err)
+			}
+
+			if got != tcase.want {
+				t.Fatalf("expected %+v, got %+v", tcase.want, got)
+			}
+		})
+	}
+}
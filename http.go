@@ -18,7 +18,6 @@ import (
 	"sync"
 
 	"github.com/alpstable/gidari/proto"
-	"github.com/alpstable/gidari/third_party/accept"
 	"golang.org/x/time/rate"
 )
 
@@ -102,36 +101,6 @@ func (svc *HTTPService) UpsertWriters(w ...proto.UpsertWriter) *HTTPService {
 	return svc
 }
 
-// isDecodeTypeJSON will check if the provided "accept" struct is typed for
-// decoding into JSON.
-func isDecodeTypeJSON(accept accept.Accept) bool {
-	return accept.Typ == "application" &&
-		(accept.Subtype == "json" || accept.Subtype == "*") ||
-		accept.Typ == "*" && accept.Subtype == "*"
-}
-
-// bestFitDecodeType will parse the provided Accept(-Charset|-Encoding|-Language)
-// header and return the header that best fits the decoding algorithm. If the
-// "Accept" header is not set, then this method will return a decodeTypeJSON.
-// If the "Accept" header is set, but no match is found, then this method will
-// return a decodeTypeUnkown.
-//
-// See the "acceptSlice.Less" method in the "third_party/accept" package for
-// more informaiton on how the "best fit" is determined.
-func bestFitDecodeType(header string) proto.DecodeType {
-	decodeType := proto.DecodeTypeUnknown
-
-	for _, accept := range accept.ParseAcceptHeader(header) {
-		if isDecodeTypeJSON(accept) {
-			decodeType = proto.DecodeTypeJSON
-
-			break
-		}
-	}
-
-	return decodeType
-}
-
 func (svc *HTTPService) upsert(ctx context.Context, jobs chan<- upsertWorkerJob, done <-chan struct{}) error {
 	for svc.Iterator.Next(ctx) {
 		rsp := svc.Iterator.Current.Response
@@ -154,7 +123,7 @@ func (svc *HTTPService) upsert(ctx context.Context, jobs chan<- upsertWorkerJob,
 
 		// Get the best fit type for decoding the response body. If the
 		// best fit is "Unknown", then return an error.
-		bestFit := bestFitDecodeType(rsp.Header.Get("Accept"))
+		bestFit, _ := NegotiateDecodeType(rsp.Header.Get("Accept"))
 		if bestFit == proto.DecodeTypeUnknown {
 			return fmt.Errorf("%w: %q", proto.ErrUnsupportedDecodeType, rsp.Request.URL.String())
 		}
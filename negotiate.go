@@ -0,0 +1,356 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package gidari
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/alpstable/gidari/proto"
+	"github.com/alpstable/gidari/third_party/accept"
+)
+
+// negotiationEntry describes the media types that can be decoded by a single
+// decode type.
+type negotiationEntry struct {
+	decodeType proto.DecodeType
+
+	// mediaTypes are the lower case "type/subtype" pairs that can be
+	// decoded by the decode type, e.g. "application/json". Media types
+	// declared with "RegisterMediaType" are appended to the built-in ones.
+	mediaTypes []string
+
+	// suffixes are the structured syntax suffixes (RFC 6839) that can be
+	// decoded by the decode type, e.g. "json" for "application/ld+json".
+	suffixes []string
+}
+
+// negotiationTable is the set of decode types supported by gidari, in order of
+// server preference. When a media range matches more than one entry (e.g.
+// "*/*"), the entry listed first wins.
+//
+//nolint:gochecknoglobals
+var negotiationTable = struct {
+	sync.RWMutex
+	entries []negotiationEntry
+}{
+	entries: []negotiationEntry{
+		{
+			decodeType: proto.DecodeTypeJSON,
+			mediaTypes: []string{"application/json"},
+			suffixes:   []string{"json"},
+		},
+	},
+}
+
+// ErrInvalidMediaType is returned when registering a media type that is not of
+// the form "type/subtype".
+var ErrInvalidMediaType = fmt.Errorf("invalid media type")
+
+// isToken will check if the provided string is a non-empty token, as defined
+// by RFC 7230. Tokens cannot contain whitespace or separators such as "/" and
+// ";".
+func isToken(str string) bool {
+	if str == "" {
+		return false
+	}
+
+	for _, r := range str {
+		isAlphaNum := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+		if !isAlphaNum && !strings.ContainsRune("!#$%&'*+-.^_`|~", r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// RegisterMediaType will register a media type, e.g.
+// "application/vnd.myexchange.v2", to be decoded using the provided decode
+// type. The media type must be a bare "type/subtype" pair without wildcards,
+// parameters, or whitespace, and the decode type must be one supported by
+// gidari.
+//
+// Registered media types are matched case-insensitively and take precedence
+// over the structured syntax suffix rules, so they can be used to resolve
+// custom vendor types that do not declare a suffix.
+//
+// Registration is process-wide and permanent: it affects every negotiation in
+// the process, and there is no way to unregister a media type. It is safe for
+// concurrent use, but is intended to be called once during initialization.
+func RegisterMediaType(mediaType string, decodeType proto.DecodeType) error {
+	typ, subtype, ok := strings.Cut(mediaType, "/")
+	if !ok || !isToken(typ) || !isToken(subtype) || typ == "*" || subtype == "*" {
+		return fmt.Errorf("%w: %q", ErrInvalidMediaType, mediaType)
+	}
+
+	mediaType = strings.ToLower(mediaType)
+
+	negotiationTable.Lock()
+	defer negotiationTable.Unlock()
+
+	for idx := range negotiationTable.entries {
+		entry := &negotiationTable.entries[idx]
+		if entry.decodeType != decodeType {
+			continue
+		}
+
+		for _, existing := range entry.mediaTypes {
+			if existing == mediaType {
+				return nil
+			}
+		}
+
+		entry.mediaTypes = append(entry.mediaTypes, mediaType)
+
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", proto.ErrUnsupportedDecodeType, decodeType)
+}
+
+// matchMediaType will check if the "type/subtype" media type is matched by the
+// parsed media range. Per RFC 7231, the only valid wildcards are "*/*", which
+// matches any media type, and "type/*", which matches any subtype of the type.
+// All comparisons are case-insensitive.
+func matchMediaType(acc accept.Accept, mediaType string) bool {
+	typ, subtype, ok := strings.Cut(mediaType, "/")
+	if !ok {
+		return false
+	}
+
+	if acc.Typ == "*" {
+		return acc.Subtype == "*"
+	}
+
+	return strings.EqualFold(acc.Typ, typ) &&
+		(acc.Subtype == "*" || strings.EqualFold(acc.Subtype, subtype))
+}
+
+// matchMediaTypes will check if the parsed media range matches one of the
+// entry's media types, returning the media type that was matched.
+func (entry negotiationEntry) matchMediaTypes(acc accept.Accept) (string, bool) {
+	for _, mediaType := range entry.mediaTypes {
+		if matchMediaType(acc, mediaType) {
+			return mediaType, true
+		}
+	}
+
+	return "", false
+}
+
+// matchSuffixes will check if the parsed media range has one of the entry's
+// structured syntax suffixes, returning the media range itself as the matched
+// media type.
+func (entry negotiationEntry) matchSuffixes(acc accept.Accept) (string, bool) {
+	// Structured syntax suffixes only apply to a specific subtype, such
+	// as "vnd.api+json".
+	idx := strings.LastIndex(acc.Subtype, "+")
+	if idx < 0 || acc.Typ == "*" {
+		return "", false
+	}
+
+	for _, suffix := range entry.suffixes {
+		if strings.EqualFold(acc.Subtype[idx+1:], suffix) {
+			return acc.Typ + "/" + acc.Subtype, true
+		}
+	}
+
+	return "", false
+}
+
+// negotiation is the result of negotiating a decode type for a media range.
+type negotiation struct {
+	decodeType proto.DecodeType
+
+	// mediaType is the concrete media type that was matched, e.g.
+	// "application/json" or "application/vnd.api+json".
+	mediaType string
+}
+
+// specificity will return how specific the parsed media range is: 2 for a
+// full "type/subtype", 1 for "type/*", and 0 for "*/*".
+func specificity(acc accept.Accept) int {
+	switch {
+	case acc.Typ == "*":
+		return 0
+	case acc.Subtype == "*":
+		return 1
+	default:
+		return 2 //nolint:gomnd
+	}
+}
+
+// candidate is the media range that determines whether, and how much, a decode
+// type is accepted.
+type candidate struct {
+	negotiation
+
+	// rangeIdx is the position of the media range in the parsed header,
+	// i.e. its rank in the client's order of preference.
+	rangeIdx int
+
+	qvalue      int
+	specificity int
+
+	// bySuffix is true if the media range was matched by a structured
+	// syntax suffix rather than by a media type.
+	bySuffix bool
+}
+
+// negotiate will parse the provided Accept header and intersect it with the
+// decode types supported by gidari. The accepted decode types are returned
+// most-preferred first, along with the media type that each was matched by.
+//
+// Each decode type is weighted by the most specific media range that matches
+// it, so "application/json;q=0, */*" does not accept JSON: a decode type whose
+// most specific range has a qvalue of 0 is excluded. Media types, including
+// registered ones, are consulted before any structured syntax suffix.
+func negotiate(header string) []negotiation {
+	negotiationTable.RLock()
+	defer negotiationTable.RUnlock()
+
+	// candidates holds the most specific media range for each entry, in
+	// the order of the negotiation table.
+	candidates := make([]*candidate, len(negotiationTable.entries))
+
+	for rangeIdx, acc := range accept.ParseAcceptHeader(header) {
+		for entryIdx, entry := range negotiationTable.entries {
+			mediaType, ok := entry.matchMediaTypes(acc)
+			bySuffix := false
+
+			if !ok {
+				mediaType, ok = entry.matchSuffixes(acc)
+				bySuffix = true
+			}
+
+			// The ranges are sorted in order of preference, so only
+			// a strictly more specific range can replace the
+			// current candidate.
+			current := candidates[entryIdx]
+			if !ok || current != nil && current.specificity >= specificity(acc) {
+				continue
+			}
+
+			candidates[entryIdx] = &candidate{
+				negotiation: negotiation{decodeType: entry.decodeType, mediaType: mediaType},
+				rangeIdx:    rangeIdx,
+				qvalue:      acc.QValue(),
+				specificity: specificity(acc),
+				bySuffix:    bySuffix,
+			}
+		}
+	}
+
+	accepted := make([]*candidate, 0, len(candidates))
+
+	for _, cand := range candidates {
+		// A qvalue of 0 marks the decode type as not acceptable.
+		if cand != nil && cand.qvalue > 0 {
+			accepted = append(accepted, cand)
+		}
+	}
+
+	// Rank by qvalue, then by the client's order of preference, then by
+	// media type matches over suffix matches. Otherwise, the order of the
+	// negotiation table is kept.
+	sort.SliceStable(accepted, func(i, j int) bool {
+		if accepted[i].qvalue != accepted[j].qvalue {
+			return accepted[i].qvalue > accepted[j].qvalue
+		}
+
+		if accepted[i].rangeIdx != accepted[j].rangeIdx {
+			return accepted[i].rangeIdx < accepted[j].rangeIdx
+		}
+
+		return !accepted[i].bySuffix && accepted[j].bySuffix
+	})
+
+	ranked := make([]negotiation, len(accepted))
+	for i, cand := range accepted {
+		ranked[i] = cand.negotiation
+	}
+
+	return ranked
+}
+
+// contentTypeDecodeType will return the decode type for the bare "type/subtype"
+// of a "Content-Type" header, e.g. as returned by "mime.ParseMediaType". Unlike
+// an Accept header, a Content-Type is a single concrete media type, so
+// wildcards never match. Media types, including registered ones, are consulted
+// before any structured syntax suffix.
+func contentTypeDecodeType(mediaType string) (proto.DecodeType, bool) {
+	typ, subtype, ok := strings.Cut(mediaType, "/")
+	if !ok || !isToken(typ) || !isToken(subtype) || typ == "*" || subtype == "*" {
+		return proto.DecodeTypeUnknown, false
+	}
+
+	acc := accept.Accept{Typ: typ, Subtype: subtype}
+
+	negotiationTable.RLock()
+	defer negotiationTable.RUnlock()
+
+	for _, match := range []func(negotiationEntry, accept.Accept) (string, bool){
+		negotiationEntry.matchMediaTypes,
+		negotiationEntry.matchSuffixes,
+	} {
+		for _, entry := range negotiationTable.entries {
+			if _, ok := match(entry, acc); ok {
+				return entry.decodeType, true
+			}
+		}
+	}
+
+	return proto.DecodeTypeUnknown, false
+}
+
+// RankDecodeTypes will parse the provided Accept header and return the decode
+// types supported by gidari that the header accepts, most-preferred first. An
+// empty header accepts every supported type. If the header accepts none of the
+// supported types, the returned slice is empty.
+//
+// Each decode type is weighted by the most specific media range that matches
+// it, so a type refused with a qvalue of 0 (e.g. "application/json;q=0, */*")
+// is excluded even if a wildcard also matches it.
+//
+// Media types declared with "RegisterMediaType" are resolved before the
+// structured syntax suffixes. When a single media range matches more
+// than one decode type (e.g. "*/*"), the types are ranked in gidari's order of
+// preference.
+func RankDecodeTypes(header string) []proto.DecodeType {
+	negotiations := negotiate(header)
+
+	ranked := make([]proto.DecodeType, len(negotiations))
+	for i, n := range negotiations {
+		ranked[i] = n.decodeType
+	}
+
+	return ranked
+}
+
+// NegotiateDecodeType will parse the provided Accept(-Charset|-Encoding|-Language)
+// header and return the decode type that best fits the decoding algorithm,
+// i.e. the first decode type returned by "RankDecodeTypes", along with the
+// concrete media type that was matched, e.g. "application/json" for "*/*" or
+// "application/vnd.api+json" for itself. The media type can be used to echo or
+// log the result of the negotiation. If the header is not set, then this
+// function will return "proto.DecodeTypeJSON" and "application/json". If the
+// header is set, but no match is found, then this function will return
+// "proto.DecodeTypeUnknown" and an empty media type.
+//
+// See the "acceptSlice.Less" method in the "third_party/accept" package for
+// more informaiton on how the "best fit" is determined.
+func NegotiateDecodeType(header string) (proto.DecodeType, string) {
+	if negotiations := negotiate(header); len(negotiations) > 0 {
+		return negotiations[0].decodeType, negotiations[0].mediaType
+	}
+
+	return proto.DecodeTypeUnknown, ""
+}
@@ -0,0 +1,288 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package gidari
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alpstable/gidari/proto"
+)
+
+func TestNegotiateDecodeType(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name          string
+		header        string
+		want          proto.DecodeType
+		wantMediaType string
+	}{
+		{
+			name:          "empty header",
+			header:        "",
+			want:          proto.DecodeTypeJSON,
+			wantMediaType: "application/json",
+		},
+		{
+			name:          "wildcard",
+			header:        "*/*",
+			want:          proto.DecodeTypeJSON,
+			wantMediaType: "application/json",
+		},
+		{
+			name:          "application wildcard",
+			header:        "application/*",
+			want:          proto.DecodeTypeJSON,
+			wantMediaType: "application/json",
+		},
+		{
+			name:          "application/json",
+			header:        "application/json",
+			want:          proto.DecodeTypeJSON,
+			wantMediaType: "application/json",
+		},
+		{
+			name:          "mixed case",
+			header:        "Application/JSON",
+			want:          proto.DecodeTypeJSON,
+			wantMediaType: "application/json",
+		},
+		{
+			name:          "json suffix",
+			header:        "application/vnd.api+json",
+			want:          proto.DecodeTypeJSON,
+			wantMediaType: "application/vnd.api+json",
+		},
+		{
+			name:          "json suffix with parameters",
+			header:        "application/ld+json;profile=expanded",
+			want:          proto.DecodeTypeJSON,
+			wantMediaType: "application/ld+json",
+		},
+		{
+			name:   "unsupported type",
+			header: "text/html",
+			want:   proto.DecodeTypeUnknown,
+		},
+		{
+			name:   "invalid wildcard",
+			header: "*/json,*/vnd.api+json",
+			want:   proto.DecodeTypeUnknown,
+		},
+		{
+			name:   "unsupported type wildcard",
+			header: "text/*",
+			want:   proto.DecodeTypeUnknown,
+		},
+		{
+			name:   "unsupported suffix",
+			header: "application/xhtml+xml",
+			want:   proto.DecodeTypeUnknown,
+		},
+		{
+			name:          "supported type with lower preference",
+			header:        "text/html,application/json;q=0.5",
+			want:          proto.DecodeTypeJSON,
+			wantMediaType: "application/json",
+		},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, gotMediaType := NegotiateDecodeType(tcase.header)
+			if got != tcase.want {
+				t.Fatalf("expected %s, got %s", tcase.want, got)
+			}
+
+			if gotMediaType != tcase.wantMediaType {
+				t.Fatalf("expected media type %q, got %q", tcase.wantMediaType, gotMediaType)
+			}
+		})
+	}
+}
+
+func TestRankDecodeTypes(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name   string
+		header string
+		want   []proto.DecodeType
+	}{
+		{
+			name:   "empty header",
+			header: "",
+			want:   []proto.DecodeType{proto.DecodeTypeJSON},
+		},
+		{
+			name:   "unsupported types",
+			header: "text/html,application/xml",
+			want:   []proto.DecodeType{},
+		},
+		{
+			name:   "supported type after unsupported types",
+			header: "text/html,application/xml;q=0.9,application/json;q=0.5",
+			want:   []proto.DecodeType{proto.DecodeTypeJSON},
+		},
+		{
+			name:   "duplicate matches",
+			header: "application/json,application/vnd.api+json;q=0.9,*/*;q=0.1",
+			want:   []proto.DecodeType{proto.DecodeTypeJSON},
+		},
+		{
+			name:   "not acceptable",
+			header: "application/json;q=0",
+			want:   []proto.DecodeType{},
+		},
+		{
+			name:   "excluded by a more specific range",
+			header: "application/json;q=0, */*",
+			want:   []proto.DecodeType{},
+		},
+		{
+			name:   "excluded by a more specific type wildcard",
+			header: "*/*, application/*;q=0",
+			want:   []proto.DecodeType{},
+		},
+		{
+			name:   "less specific exclusion",
+			header: "*/*;q=0, application/json;q=0.5",
+			want:   []proto.DecodeType{proto.DecodeTypeJSON},
+		},
+		{
+			name:   "excluded suffix does not exclude the type",
+			header: "application/vnd.api+json;q=0, application/json",
+			want:   []proto.DecodeType{proto.DecodeTypeJSON},
+		},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := RankDecodeTypes(tcase.header)
+			if len(got) != len(tcase.want) {
+				t.Fatalf("expected %v, got %v", tcase.want, got)
+			}
+
+			for i := range got {
+				if got[i] != tcase.want[i] {
+					t.Fatalf("expected %v, got %v", tcase.want, got)
+				}
+			}
+		})
+	}
+}
+
+// snapshotNegotiationTable will copy the global negotiation table and restore
+// it when the test and all of its subtests complete.
+func snapshotNegotiationTable(t *testing.T) {
+	t.Helper()
+
+	negotiationTable.RLock()
+	defer negotiationTable.RUnlock()
+
+	entries := make([]negotiationEntry, len(negotiationTable.entries))
+	for idx, entry := range negotiationTable.entries {
+		entry.mediaTypes = append([]string(nil), entry.mediaTypes...)
+		entries[idx] = entry
+	}
+
+	t.Cleanup(func() {
+		negotiationTable.Lock()
+		defer negotiationTable.Unlock()
+
+		negotiationTable.entries = entries
+	})
+}
+
+// TestRegisterMediaType is not run in parallel, since registering media types
+// modifies the global negotiation table. Go runs it before resuming any of the
+// parallel tests, and the table is restored once it completes.
+//
+//nolint:paralleltest
+func TestRegisterMediaType(t *testing.T) {
+	snapshotNegotiationTable(t)
+
+	t.Run("invalid", func(t *testing.T) {
+
+		for _, tcase := range []struct {
+			mediaType  string
+			decodeType proto.DecodeType
+			err        error
+		}{
+			{mediaType: "", decodeType: proto.DecodeTypeJSON, err: ErrInvalidMediaType},
+			{mediaType: "application", decodeType: proto.DecodeTypeJSON, err: ErrInvalidMediaType},
+			{mediaType: "application/*", decodeType: proto.DecodeTypeJSON, err: ErrInvalidMediaType},
+			{mediaType: "*/*", decodeType: proto.DecodeTypeJSON, err: ErrInvalidMediaType},
+			{mediaType: " application/vnd.x", decodeType: proto.DecodeTypeJSON, err: ErrInvalidMediaType},
+			{mediaType: "application /vnd.x", decodeType: proto.DecodeTypeJSON, err: ErrInvalidMediaType},
+			{mediaType: "application/vnd.x; v=2", decodeType: proto.DecodeTypeJSON, err: ErrInvalidMediaType},
+			{mediaType: "application/vnd.x;v=2", decodeType: proto.DecodeTypeJSON, err: ErrInvalidMediaType},
+			{mediaType: "application/vnd/x", decodeType: proto.DecodeTypeJSON, err: ErrInvalidMediaType},
+			{mediaType: "application/vnd.x", decodeType: proto.DecodeTypeUnknown, err: proto.ErrUnsupportedDecodeType},
+			{mediaType: "application/vnd.x", decodeType: proto.DecodeType(99), err: proto.ErrUnsupportedDecodeType},
+		} {
+			if err := RegisterMediaType(tcase.mediaType, tcase.decodeType); !errors.Is(err, tcase.err) {
+				t.Errorf("%q: expected error %v, got %v", tcase.mediaType, tcase.err, err)
+			}
+		}
+	})
+
+	t.Run("vendor type", func(t *testing.T) {
+		const header = "application/vnd.gidari-test.v2"
+
+		if got, _ := NegotiateDecodeType(header); got != proto.DecodeTypeUnknown {
+			t.Fatalf("expected %s before registering, got %s", proto.DecodeTypeUnknown, got)
+		}
+
+		if err := RegisterMediaType("Application/Vnd.Gidari-Test.V2", proto.DecodeTypeJSON); err != nil {
+			t.Fatalf("failed to register media type: %v", err)
+		}
+
+		got, mediaType := NegotiateDecodeType(header)
+		if got != proto.DecodeTypeJSON {
+			t.Fatalf("expected %s after registering, got %s", proto.DecodeTypeJSON, got)
+		}
+
+		if mediaType != header {
+			t.Fatalf("expected media type %q, got %q", header, mediaType)
+		}
+
+		// Registered media types also resolve a response Content-Type.
+		if got, ok := contentTypeDecodeType(header); !ok || got != proto.DecodeTypeJSON {
+			t.Fatalf("expected Content-Type to resolve to %s, got %s", proto.DecodeTypeJSON, got)
+		}
+	})
+
+	t.Run("restored", func(t *testing.T) {
+		snapshot := func() int {
+			negotiationTable.RLock()
+			defer negotiationTable.RUnlock()
+
+			return len(negotiationTable.entries[0].mediaTypes)
+		}
+
+		before := snapshot()
+
+		t.Run("register", func(t *testing.T) {
+			snapshotNegotiationTable(t)
+
+			if err := RegisterMediaType("application/vnd.gidari-test.v3", proto.DecodeTypeJSON); err != nil {
+				t.Fatalf("failed to register media type: %v", err)
+			}
+		})
+
+		if after := snapshot(); after != before {
+			t.Fatalf("expected %d media types after restoring, got %d", before, after)
+		}
+	})
+}
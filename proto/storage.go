@@ -18,6 +18,19 @@ const (
 	DecodeTypeJSON
 )
 
+// String will return the name of the decode type, e.g. "json". Any value that
+// is not a defined decode type will be named "unknown".
+func (dt DecodeType) String() string {
+	switch dt {
+	case DecodeTypeJSON:
+		return "json"
+	case DecodeTypeUnknown:
+		fallthrough
+	default:
+		return "unknown"
+	}
+}
+
 type UpsertWriter interface {
 	// Upsert will use an UpsertRequest to upsert a new or existing
 	// object into the storage backend.
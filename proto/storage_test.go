@@ -0,0 +1,40 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package proto
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDecodeTypeString(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		decodeType DecodeType
+		want       string
+	}{
+		{decodeType: DecodeTypeUnknown, want: "unknown"},
+		{decodeType: DecodeTypeJSON, want: "json"},
+		{decodeType: DecodeType(-1), want: "unknown"},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.want, func(t *testing.T) {
+			t.Parallel()
+
+			// Ensure that the decode type satisfies the
+			// "fmt.Stringer" interface.
+			var stringer fmt.Stringer = tcase.decodeType
+
+			if got := stringer.String(); got != tcase.want {
+				t.Fatalf("expected %q, got %q", tcase.want, got)
+			}
+		})
+	}
+}
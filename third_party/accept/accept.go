@@ -31,6 +31,7 @@ package accept
 
 import (
 	"fmt"
+	"math"
 	"sort"
 	"strconv"
 	"strings"
@@ -38,6 +39,11 @@ import (
 
 var errInvalidTypeSubtype = "accept: Invalid type '%s'."
 
+var errInvalidQValue = "accept: Invalid qvalue '%s'."
+
+// MaxQValue is the largest qvalue, expressed in thousandths.
+const MaxQValue = 1000
+
 // Accept represents a parsed Accept(-Charset|-Encoding|-Language) header.
 type Accept struct {
 	Typ, Subtype  string
@@ -45,6 +51,13 @@ type Accept struct {
 	Extensions    map[string]string
 }
 
+// QValue returns the quality factor expressed in thousandths (0-1000). The spec
+// allows up to three decimal digits, so entries are compared using this value
+// to avoid floating point comparisons.
+func (a Accept) QValue() int {
+	return int(math.Round(a.QualityFactor * MaxQValue))
+}
+
 // AcceptSlice is a slice of Accept.
 type AcceptSlice []Accept
 
@@ -57,9 +70,9 @@ func (a AcceptSlice) Len() int {
 // sorted in order of decreasing preference.
 func (a AcceptSlice) Less(i, j int) bool {
 	// Higher qvalues come first.
-	if a[i].QualityFactor > a[j].QualityFactor {
+	if a[i].QValue() > a[j].QValue() {
 		return true
-	} else if a[i].QualityFactor < a[j].QualityFactor {
+	} else if a[i].QValue() < a[j].QValue() {
 		return false
 	}
 
@@ -90,10 +103,90 @@ func (a AcceptSlice) Swap(i, j int) {
 	a[i], a[j] = a[j], a[i]
 }
 
+// String renders the element as a media range, e.g. "text/html;level=1;q=0.5".
+// Extensions are rendered in lexical order, and the qvalue is omitted when it
+// is 1.
+func (a Accept) String() string {
+	var b strings.Builder
+
+	b.WriteString(a.Typ)
+	b.WriteByte('/')
+	b.WriteString(a.Subtype)
+
+	names := make([]string, 0, len(a.Extensions))
+	for name := range a.Extensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		b.WriteByte(';')
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(a.Extensions[name])
+	}
+
+	if qval := a.QValue(); qval != MaxQValue {
+		b.WriteString(";q=")
+		b.WriteString(FormatQValue(qval))
+	}
+
+	return b.String()
+}
+
+// String renders the slice as an Accept header value, which parses back into
+// an equivalent AcceptSlice. Since an empty header accepts everything, an
+// empty slice is rendered as "*/*;q=0", which parses back into a slice that
+// accepts nothing.
+func (a AcceptSlice) String() string {
+	if len(a) == 0 {
+		return "*/*;q=0"
+	}
+
+	ranges := make([]string, len(a))
+	for i, accept := range a {
+		ranges[i] = accept.String()
+	}
+
+	return strings.Join(ranges, ", ")
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (a AcceptSlice) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// Filter returns the elements with a qvalue at or above the provided minimum,
+// preserving their order. The minimum is rounded to the nearest thousandth, as
+// is done when parsing qvalues.
+func (a AcceptSlice) Filter(minQ float64) AcceptSlice {
+	minQValue := int(math.Round(minQ * MaxQValue))
+
+	filtered := make(AcceptSlice, 0, len(a))
+	for _, accept := range a {
+		if accept.QValue() >= minQValue {
+			filtered = append(filtered, accept)
+		}
+	}
+
+	return filtered
+}
+
 // parseMediaRange parses the provided media range, and on success returns the
-// parsed range params and type/subtype pair.
+// parsed range params and type/subtype pair. All tokens are trimmed of leading
+// and trailing whitespace (OWS). A single trailing ";" is allowed, but any
+// other empty range param is left in place to be rejected as invalid.
 func parseMediaRange(mediaRange string) (rangeParams, typeSubtype []string, err error) {
 	rangeParams = strings.Split(mediaRange, ";")
+
+	// Sanitize rangeParams.
+	for i := range rangeParams[1:] {
+		rangeParams[i+1] = strings.TrimSpace(rangeParams[i+1])
+	}
+	if last := len(rangeParams) - 1; last > 0 && rangeParams[last] == "" {
+		rangeParams = rangeParams[:last]
+	}
+
 	typeSubtype = strings.Split(rangeParams[0], "/")
 
 	// typeSubtype should have a length of exactly two.
@@ -117,11 +210,34 @@ func parseMediaRange(mediaRange string) (rangeParams, typeSubtype []string, err
 	return
 }
 
+// ParseQValue parses the provided qvalue into thousandths, rounding any digits
+// past the third decimal. Values above 1 are clamped to 1000. Negative values
+// are returned as-is, and should be treated as invalid by the caller.
+func ParseQValue(qvalue string) (int, error) {
+	qval, err := strconv.ParseFloat(qvalue, 64)
+	if err != nil || math.IsNaN(qval) {
+		return 0, fmt.Errorf(errInvalidQValue, qvalue)
+	}
+
+	if qval > 1.0 {
+		return MaxQValue, nil
+	}
+
+	return int(math.Round(qval * MaxQValue)), nil
+}
+
+// FormatQValue renders a qvalue expressed in thousandths, e.g. 500 as "0.5".
+func FormatQValue(qvalue int) string {
+	return strconv.FormatFloat(float64(qvalue)/MaxQValue, 'f', -1, 64)
+}
+
 // ParseAcceptHeader parses a HTTP Accept(-Charset|-Encoding|-Language) header and returns
 // AcceptSlice, sorted in decreasing order of preference.  If the header lists
 // multiple types that have the same level of preference (same specificity of
 // type and subtype, same qvalue, and same number of extensions), the type
 // that was listed in the header first comes first in the returned value.
+// Entries with a qvalue of 0 are kept, since they explicitly mark a type as not
+// acceptable.
 //
 // See http://www.w3.org/Protocols/rfc2616/rfc2616-sec14 for more information.
 func ParseAcceptHeader(header string) AcceptSlice {
@@ -157,15 +273,12 @@ func ParseAcceptHeader(header string) AcceptSlice {
 			}
 			nameVal[1] = strings.TrimSpace(nameVal[1])
 			if name := strings.TrimSpace(nameVal[0]); name == "q" {
-				qval, err := strconv.ParseFloat(nameVal[1], 64)
+				qval, err := ParseQValue(nameVal[1])
 				if err != nil || qval < 0 {
 					validParams = false
 					break
 				}
-				if qval > 1.0 {
-					qval = 1.0
-				}
-				accept.QualityFactor = qval
+				accept.QualityFactor = float64(qval) / MaxQValue
 			} else {
 				accept.Extensions[name] = nameVal[1]
 			}
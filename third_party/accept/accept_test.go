@@ -29,7 +29,11 @@
 // SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
 package accept
 
-import "testing"
+import (
+	"encoding"
+	"sort"
+	"testing"
+)
 
 func TestParseAcceptHeader(t *testing.T) {
 	type parseTest struct {
@@ -254,6 +258,239 @@ func TestParseAcceptHeader(t *testing.T) {
 	}
 }
 
+func TestAcceptSliceSort(t *testing.T) {
+	// Elements built by hand only set the QualityFactor.
+	accepted := AcceptSlice{
+		{Typ: "text", Subtype: "a", QualityFactor: 0.333},
+		{Typ: "text", Subtype: "b", QualityFactor: 0.334},
+		{Typ: "text", Subtype: "c", QualityFactor: 1},
+	}
+
+	sort.Sort(accepted)
+
+	for i, subtype := range []string{"c", "b", "a"} {
+		if accepted[i].Subtype != subtype {
+			t.Errorf("Sort (%d): expected subtype '%v', received '%v'.", i, subtype, accepted[i].Subtype)
+		}
+	}
+}
+
+func TestParseAcceptHeaderWhitespace(t *testing.T) {
+	want := AcceptSlice{
+		{
+			Typ:           "text",
+			Subtype:       "html",
+			QualityFactor: 1,
+			Extensions:    map[string]string{},
+		},
+		{
+			Typ:           "application",
+			Subtype:       "json",
+			QualityFactor: 0.5,
+			Extensions: map[string]string{
+				"charset": "utf-8",
+			},
+		},
+	}
+
+	for _, input := range []string{
+		"text/html,application/json;charset=utf-8;q=0.5",
+		"text/html,application/json;\tcharset=utf-8;\tq=0.5",
+		"text/html,\tapplication/json\t;\tcharset\t=\tutf-8\t;\tq\t=\t0.5\t",
+		" \ttext/html \t, \tapplication \t/ \tjson; charset=utf-8 ;q=0.5",
+		"text/html,application/json;charset=utf-8;q=0.5;",
+		"text/html,application/json;charset=utf-8;q=0.5;\t",
+	} {
+		accepted := ParseAcceptHeader(input)
+		if len(accepted) != len(want) {
+			t.Errorf("Parse (%q): expected %d elements, received %d.", input, len(want), len(accepted))
+			continue
+		}
+		for i, a := range accepted {
+			if a.Typ != want[i].Typ {
+				t.Errorf("Parse (%q.%d): expected type '%v', received '%v'.", input, i, want[i].Typ, a.Typ)
+			}
+			if a.Subtype != want[i].Subtype {
+				t.Errorf("Parse (%q.%d): expected subtype '%v', received '%v'.", input, i, want[i].Subtype, a.Subtype)
+			}
+			if a.QualityFactor != want[i].QualityFactor {
+				t.Errorf("Parse (%q.%d): expected qval '%v', received '%v'.", input, i, want[i].QualityFactor, a.QualityFactor)
+			}
+			if !mapsAreSimilar(t, a.Extensions, want[i].Extensions) {
+				t.Errorf("Parse (%q.%d): expected extensions '%v', received '%v'.", input, i, want[i].Extensions, a.Extensions)
+			}
+		}
+	}
+}
+
+func TestParseAcceptHeaderEmptyParams(t *testing.T) {
+	for _, input := range []string{
+		"text/html;;q=0.5",
+		"text/html;\t;q=0.5",
+		"text/html;q=0.5;;",
+	} {
+		if accepted := ParseAcceptHeader(input); len(accepted) != 0 {
+			t.Errorf("Parse (%q): expected 0 elements, received %d.", input, len(accepted))
+		}
+	}
+}
+
+func TestParseAcceptHeaderQValue(t *testing.T) {
+	for _, test := range []struct {
+		input    string
+		subtypes []string
+		qvalues  []int
+	}{
+		{
+			// Entries that differ only in the third decimal.
+			input:    "text/a;q=0.333,text/b;q=0.334",
+			subtypes: []string{"b", "a"},
+			qvalues:  []int{334, 333},
+		},
+		{
+			// Digits past the third decimal are rounded.
+			input:    "text/a;q=0.3334,text/b;q=0.3336",
+			subtypes: []string{"b", "a"},
+			qvalues:  []int{334, 333},
+		},
+		{
+			// Values above 1 are clamped.
+			input:    "text/a;q=0.999,text/b;q=1.5",
+			subtypes: []string{"b", "a"},
+			qvalues:  []int{1000, 999},
+		},
+		{
+			// A qvalue of 0 is kept to mark the type as not
+			// acceptable.
+			input:    "text/a;q=0,text/b;q=0.000,text/c;q=0.0004,text/d;q=0.001",
+			subtypes: []string{"d", "a", "b", "c"},
+			qvalues:  []int{1, 0, 0, 0},
+		},
+		{
+			input:    "text/a;q=NaN",
+			subtypes: []string{},
+			qvalues:  []int{},
+		},
+	} {
+		accepted := ParseAcceptHeader(test.input)
+		if len(accepted) != len(test.subtypes) {
+			t.Errorf("Parse (%q): expected %d elements, received %d.", test.input, len(test.subtypes), len(accepted))
+			continue
+		}
+		for i, a := range accepted {
+			if a.Subtype != test.subtypes[i] {
+				t.Errorf("Parse (%q.%d): expected subtype '%v', received '%v'.", test.input, i, test.subtypes[i], a.Subtype)
+			}
+			if a.QValue() != test.qvalues[i] {
+				t.Errorf("Parse (%q.%d): expected qvalue '%v', received '%v'.", test.input, i, test.qvalues[i], a.QValue())
+			}
+		}
+	}
+}
+
+func TestAcceptSliceFilter(t *testing.T) {
+	accepted := ParseAcceptHeader("text/html,application/xml;q=0.9,application/json;q=0.899,*/*;q=0.1")
+
+	for _, test := range []struct {
+		minQ     float64
+		subtypes []string
+	}{
+		{minQ: 0, subtypes: []string{"html", "xml", "json", "*"}},
+		{minQ: 0.1, subtypes: []string{"html", "xml", "json", "*"}},
+		{minQ: 0.5, subtypes: []string{"html", "xml", "json"}},
+		{minQ: 0.899, subtypes: []string{"html", "xml", "json"}},
+		{minQ: 0.9, subtypes: []string{"html", "xml"}},
+		{minQ: 1, subtypes: []string{"html"}},
+		{minQ: 1.1, subtypes: []string{}},
+	} {
+		filtered := accepted.Filter(test.minQ)
+		if len(filtered) != len(test.subtypes) {
+			t.Errorf("Filter (%v): expected %d elements, received %d.", test.minQ, len(test.subtypes), len(filtered))
+			continue
+		}
+		for i, a := range filtered {
+			if a.Subtype != test.subtypes[i] {
+				t.Errorf("Filter (%v.%d): expected subtype '%v', received '%v'.", test.minQ, i, test.subtypes[i], a.Subtype)
+			}
+		}
+	}
+}
+
+func TestAcceptSliceString(t *testing.T) {
+	for _, test := range []struct {
+		input  string
+		output string
+	}{
+		{
+			input:  "",
+			output: "*/*",
+		},
+		{
+			input:  "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			output: "text/html, application/xhtml+xml, application/xml;q=0.9, */*;q=0.8",
+		},
+		{
+			input:  "text/plain; q=0.333; b=2; a=1, text/*;q=0.334",
+			output: "text/*;q=0.334, text/plain;a=1;b=2;q=0.333",
+		},
+		{
+			input:  "application/json;q=1.5;charset=utf-8",
+			output: "application/json;charset=utf-8",
+		},
+		{
+			// An explicit refusal.
+			input:  "text/html;q=0",
+			output: "text/html;q=0",
+		},
+	} {
+		accepted := ParseAcceptHeader(test.input)
+
+		// The slice should be usable wherever text can be marshaled.
+		var marshaler encoding.TextMarshaler = accepted
+
+		text, err := marshaler.MarshalText()
+		if err != nil {
+			t.Fatalf("MarshalText (%q): unexpected error: %v", test.input, err)
+		}
+
+		if string(text) != test.output {
+			t.Errorf("String (%q): expected '%v', received '%v'.", test.input, test.output, string(text))
+		}
+
+		// The rendered header should round-trip to an equivalent
+		// slice.
+		reparsed := ParseAcceptHeader(string(text))
+		if len(reparsed) != len(accepted) {
+			t.Errorf("Round trip (%q): expected %d elements, received %d.", test.input, len(accepted), len(reparsed))
+			continue
+		}
+		for i, a := range reparsed {
+			if a.Typ != accepted[i].Typ || a.Subtype != accepted[i].Subtype || a.QValue() != accepted[i].QValue() ||
+				!mapsAreSimilar(t, a.Extensions, accepted[i].Extensions) {
+				t.Errorf("Round trip (%q.%d): expected '%v', received '%v'.", test.input, i, accepted[i], a)
+			}
+		}
+	}
+}
+
+func TestAcceptSliceStringFiltered(t *testing.T) {
+	// Filtering out every element should not render a header that
+	// accepts everything.
+	filtered := ParseAcceptHeader("text/html;q=0.5").Filter(0.9)
+	if reparsed := ParseAcceptHeader(filtered.String()); len(reparsed.Filter(0.001)) != 0 {
+		t.Errorf("Round trip (%q): expected nothing to be acceptable, received %v.", filtered.String(), reparsed)
+	}
+
+	// Elements built by hand only set the QualityFactor.
+	accepted := AcceptSlice{
+		{Typ: "text", Subtype: "html", QualityFactor: 1},
+		{Typ: "application", Subtype: "json", QualityFactor: 0.5},
+	}
+	if want := "text/html, application/json;q=0.5"; accepted.String() != want {
+		t.Errorf("String: expected '%v', received '%v'.", want, accepted.String())
+	}
+}
+
 func mapsAreSimilar(t *testing.T, a, b map[string]string) bool {
 	t.Helper()
 
@@ -0,0 +1,151 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package gidari
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/alpstable/gidari/third_party/accept"
+)
+
+// ErrInvalidAcceptLanguage is returned when an "Accept-Language" language range
+// cannot be parsed.
+var ErrInvalidAcceptLanguage = fmt.Errorf("invalid Accept-Language")
+
+// maxLanguageSubtagLen is the maximum length of a language range subtag, per
+// RFC 4647.
+const maxLanguageSubtagLen = 8
+
+// isLanguageRange will check if the provided tag is a valid basic language
+// range, e.g. "*", "en", or "en-GB".
+func isLanguageRange(tag string) bool {
+	if tag == "*" {
+		return true
+	}
+
+	for idx, subtag := range strings.Split(tag, "-") {
+		if subtag == "" || len(subtag) > maxLanguageSubtagLen {
+			return false
+		}
+
+		for _, r := range subtag {
+			isAlpha := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+			isDigit := r >= '0' && r <= '9'
+
+			// Only the subtags following the primary subtag may
+			// contain digits.
+			if !isAlpha && (idx == 0 || !isDigit) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// parseLanguageRange will validate a single weighted language range, e.g.
+// "en-GB;q=0.8", and return it in canonical form. The range is parsed the same
+// way as any other Accept header, and the only parameter it may have is the
+// "q" weight. A weight of 0 is kept, since it marks the language range as not
+// acceptable.
+func parseLanguageRange(langRange string) (string, error) {
+	langRange = strings.TrimSpace(langRange)
+
+	parsed := accept.ParseAcceptHeader(langRange)
+	if langRange == "" || strings.Contains(langRange, "/") || len(parsed) != 1 {
+		return "", fmt.Errorf("%w: invalid language range %q", ErrInvalidAcceptLanguage, langRange)
+	}
+
+	if acc := parsed[0]; !isLanguageRange(acc.Typ) || len(acc.Extensions) != 0 {
+		return "", fmt.Errorf("%w: invalid language range %q", ErrInvalidAcceptLanguage, langRange)
+	}
+
+	if qval := parsed[0].QValue(); qval != accept.MaxQValue {
+		return parsed[0].Typ + ";q=" + accept.FormatQValue(qval), nil
+	}
+
+	return parsed[0].Typ, nil
+}
+
+// parseAcceptLanguage will validate each of the provided language ranges and
+// join them into a single "Accept-Language" header value. The header is built
+// from the parsed ranges, so whitespace around the separators is dropped.
+func parseAcceptLanguage(languages ...string) (string, error) {
+	ranges := make([]string, 0, len(languages))
+
+	for _, language := range languages {
+		for _, langRange := range strings.Split(language, ",") {
+			parsed, err := parseLanguageRange(langRange)
+			if err != nil {
+				return "", err
+			}
+
+			ranges = append(ranges, parsed)
+		}
+	}
+
+	if len(ranges) == 0 {
+		return "", fmt.Errorf("%w: no language ranges", ErrInvalidAcceptLanguage)
+	}
+
+	return strings.Join(ranges, ", "), nil
+}
+
+// AcceptLanguageTransport is an "http.RoundTripper" that will set the
+// "Accept-Language" header on outbound requests that do not already set one.
+// To use it with an HTTPService, set it as the "Transport" of an "http.Client"
+// and pass the client to the "Client" method.
+//
+// Use "NewAcceptLanguageTransport" to create the transport. A zero-value
+// transport has no language ranges and passes requests through unchanged.
+type AcceptLanguageTransport struct {
+	// Transport is the underlying round tripper used to make the request.
+	// If no transport is set, the "http.DefaultTransport" defined by the
+	// "net/http" package will be used.
+	Transport http.RoundTripper
+
+	header string
+}
+
+// NewAcceptLanguageTransport will return a transport that sets the
+// "Accept-Language" header to the provided language ranges. The ranges can be
+// a single value (e.g. "en-US") or a weighted list, either as separate
+// arguments or comma-separated (e.g. "da", "en-GB;q=0.8", "en;q=0.7"). An
+// error is returned if any of the ranges is invalid.
+func NewAcceptLanguageTransport(languages ...string) (*AcceptLanguageTransport, error) {
+	header, err := parseAcceptLanguage(languages...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AcceptLanguageTransport{header: header}, nil
+}
+
+// RoundTrip implements the "http.RoundTripper" interface. The request is
+// cloned before the header is set, so the caller's request is not modified.
+func (t *AcceptLanguageTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := t.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	// A zero-value transport has no header to set.
+	if t.header != "" && req.Header.Get("Accept-Language") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Accept-Language", t.header)
+	}
+
+	rsp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to round trip: %w", err)
+	}
+
+	return rsp, nil
+}
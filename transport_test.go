@@ -0,0 +1,223 @@
+// Copyright 2022 The Gidari Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+package gidari
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/alpstable/gidari/third_party/accept"
+)
+
+// roundTripperFunc is an adapter to allow the use of ordinary functions as
+// HTTP round trippers.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+func TestAcceptLanguageTransport(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name      string
+		languages []string
+
+		// requestHeader is the "Accept-Language" header already set
+		// on the request, if any.
+		requestHeader string
+
+		want string
+		err  error
+	}{
+		{
+			name:      "single value",
+			languages: []string{"en-US"},
+			want:      "en-US",
+		},
+		{
+			name:      "weighted list",
+			languages: []string{"da", "en-GB;q=0.8", "en;q=0.7"},
+			want:      "da, en-GB;q=0.8, en;q=0.7",
+		},
+		{
+			name:      "comma separated weighted list",
+			languages: []string{"da,\ten-GB;q=0.8"},
+			want:      "da, en-GB;q=0.8",
+		},
+		{
+			name:      "wildcard",
+			languages: []string{"fr", "*;q=0.5"},
+			want:      "fr, *;q=0.5",
+		},
+		{
+			name:          "header already set",
+			languages:     []string{"en-US"},
+			requestHeader: "fr-CA",
+			want:          "fr-CA",
+		},
+		{
+			name:      "internal whitespace",
+			languages: []string{" en-GB \t;  q = 0.8 ,fr ; q=1.000"},
+			want:      "en-GB;q=0.8, fr",
+		},
+		{
+			name:      "not acceptable",
+			languages: []string{"en", "*;q=0"},
+			want:      "en, *;q=0",
+		},
+		{
+			name:      "three decimal qvalue",
+			languages: []string{"en;q=0.333", "fr;q=0.", "de;q=1."},
+			want:      "en;q=0.333, fr;q=0, de",
+		},
+		{
+			// Weights are parsed the same way as for the
+			// "Accept" header, i.e. clamped to 1 and rounded to
+			// three decimals.
+			name:      "qvalue clamping and rounding",
+			languages: []string{"en;q=5", "fr;q=1.001", "de;q=0.3333"},
+			want:      "en, fr, de;q=0.333",
+		},
+		{
+			name: "no languages",
+			err:  ErrInvalidAcceptLanguage,
+		},
+		{
+			name:      "media range",
+			languages: []string{"text/html"},
+			err:       ErrInvalidAcceptLanguage,
+		},
+		{
+			name:      "invalid qvalue",
+			languages: []string{"en;q=INVALID"},
+			err:       ErrInvalidAcceptLanguage,
+		},
+		{
+			name:      "negative qvalue",
+			languages: []string{"en;q=-0.5"},
+			err:       ErrInvalidAcceptLanguage,
+		},
+		{
+			name:      "extension parameter",
+			languages: []string{"en;foo=bar;q=0.5"},
+			err:       ErrInvalidAcceptLanguage,
+		},
+		{
+			name:      "invalid subtag",
+			languages: []string{"en-toolongsubtag"},
+			err:       ErrInvalidAcceptLanguage,
+		},
+		{
+			name:      "empty range",
+			languages: []string{"en,,fr"},
+			err:       ErrInvalidAcceptLanguage,
+		},
+	} {
+		tcase := tcase
+
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			transport, err := NewAcceptLanguageTransport(tcase.languages...)
+			if !errors.Is(err, tcase.err) {
+				t.Fatalf("expected error %v, got %v", tcase.err, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			var got string
+
+			transport.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				got = req.Header.Get("Accept-Language")
+
+				return &http.Response{StatusCode: http.StatusOK, Request: req}, nil
+			})
+
+			req, err := http.NewRequest(http.MethodGet, "http://example", nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+
+			if tcase.requestHeader != "" {
+				req.Header.Set("Accept-Language", tcase.requestHeader)
+			}
+
+			//nolint:bodyclose
+			if _, err := transport.RoundTrip(req); err != nil {
+				t.Fatalf("failed to round trip: %v", err)
+			}
+
+			if got != tcase.want {
+				t.Fatalf("expected Accept-Language %q, got %q", tcase.want, got)
+			}
+
+			// The caller's request should not be modified.
+			if req.Header.Get("Accept-Language") != tcase.requestHeader {
+				t.Fatalf("expected request header to be unmodified, got %q",
+					req.Header.Get("Accept-Language"))
+			}
+		})
+	}
+}
+
+func TestAcceptLanguageTransportZeroValue(t *testing.T) {
+	t.Parallel()
+
+	var got []string
+
+	transport := &AcceptLanguageTransport{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			got = req.Header.Values("Accept-Language")
+
+			return &http.Response{StatusCode: http.StatusOK, Request: req}, nil
+		}),
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "http://example", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+
+	//nolint:bodyclose
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatalf("failed to round trip: %v", err)
+	}
+
+	if len(got) != 0 {
+		t.Fatalf("expected no Accept-Language header, got %q", got)
+	}
+}
+
+func TestAcceptLanguageTransportRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	transport, err := NewAcceptLanguageTransport("en", "*;q=0", "fr;q=0.5")
+	if err != nil {
+		t.Fatalf("failed to create transport: %v", err)
+	}
+
+	// The header should parse back into the same weights, including the
+	// "*;q=0" refusal.
+	want := map[string]int{"en": 1000, "*": 0, "fr": 500}
+
+	parsed := accept.ParseAcceptHeader(transport.header)
+	if len(parsed) != len(want) {
+		t.Fatalf("expected %d language ranges, got %v", len(want), parsed)
+	}
+
+	for _, acc := range parsed {
+		if qval, ok := want[acc.Typ]; !ok || acc.QValue() != qval {
+			t.Fatalf("unexpected language range %v", acc)
+		}
+	}
+}